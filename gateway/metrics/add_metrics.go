@@ -5,110 +5,166 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	"net/http/httptest"
-	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/openfaas/faas/gateway/requests"
 )
 
+// makeClient builds a shared http.Client for talking to a metrics backend,
+// tuned to fail fast and reuse connections across batched queries.
 func makeClient() http.Client {
-	// Fine-tune the client to fail fast.
-	return http.Client{}
+	return http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConns:        20,
+			MaxIdleConnsPerHost: 20,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
 }
 
-// AddMetricsHandler wraps a http.HandlerFunc with Prometheus metrics
-func AddMetricsHandler(handler http.HandlerFunc, prometheusQuery PrometheusQueryFetcher) http.HandlerFunc {
+// AddMetricsHandler wraps a http.HandlerFunc with metrics from backend, so
+// operators running Prometheus, InfluxDB (via Telegraf), or nothing
+// at all (metrics.Noop) can all populate the same requests.Function fields.
+// The handler itself is instrumented via exporter, so every call is counted,
+// timed and tracked in-flight, and internal failures increment exporter's
+// error counter instead of only being logged.
+func AddMetricsHandler(handler http.HandlerFunc, backend Backend, exporter *Exporter) http.HandlerFunc {
+	instrumented := exporter.Instrument(handler)
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		// log.Printf("Calling upstream for function info\n")
+		result, fetchErr := fetchMetrics(instrumented, backend, exporter, r)
+		if fetchErr != nil {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(fetchErr.Status)
+			w.Write([]byte(fetchErr.Body))
+			return
+		}
 
-		recorder := httptest.NewRecorder()
-		handler.ServeHTTP(recorder, r)
-		upstreamCall := recorder.Result()
+		if result.EmptyUpstream {
+			return
+		}
 
-		if upstreamCall.Body == nil {
-			log.Println("Upstream call had empty body.")
+		if result.Fallback != nil {
+			writeResponse(w, result.Fallback)
 			return
 		}
 
-		defer upstreamCall.Body.Close()
+		if len(result.Warnings) > 0 {
+			w.Header().Set("X-Prometheus-Warnings", strings.Join(result.Warnings, "; "))
+		}
 
-		if recorder.Code != http.StatusOK {
-			w.Header().Set("Content-Type", "text/plain")
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf("Error pulling metrics from provider/backend. Status code: %d", recorder.Code)))
+		bytesOut, marshalErr := json.Marshal(result.Functions)
+		if marshalErr != nil {
+			log.Println(marshalErr)
+			exporter.IncHandlerInternalError()
 			return
 		}
 
-		upstreamBody, _ := ioutil.ReadAll(upstreamCall.Body)
-		var functions []requests.Function
+		// log.Printf("Writing bytesOut: %s\n", bytesOut)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(bytesOut)
+	}
+}
 
-		err := json.Unmarshal(upstreamBody, &functions)
+// metricsFetchResult is the outcome of calling the upstream handler and mixing
+// in the backend's metrics. The response is always a bare []requests.Function,
+// matching the unenriched Fallback body, so callers see one shape regardless
+// of whether the backend was healthy; any warnings travel out-of-band via the
+// X-Prometheus-Warnings header instead of changing that shape.
+type metricsFetchResult struct {
+	// Functions holds the enriched function list, set when the backend was queried successfully
+	Functions []requests.Function
 
-		if err != nil {
-			log.Printf("Metrics upstream error: %s", err)
+	// Warnings holds any non-fatal storage warnings collected while enriching Functions
+	Warnings []string
 
-			w.Header().Set("Content-Type", "text/plain")
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Error parsing metrics from upstream provider/backend."))
-			return
-		}
+	// Fallback holds the raw, unenriched upstream body, set when the backend query failed
+	Fallback []byte
 
-		// log.Printf("Querying Prometheus API\n")
-		expr := url.QueryEscape(`sum(gateway_function_invocation_total{function_name=~".*", code=~".*"}) by (function_name, code)`)
-		// expr := "sum(gateway_function_invocation_total%7Bfunction_name%3D~%22.*%22%2C+code%3D~%22.*%22%7D)+by+(function_name%2C+code)"
-		results, fetchErr := prometheusQuery.Fetch(expr)
-		if fetchErr != nil {
-			log.Printf("Error querying Prometheus API: %s\n", fetchErr.Error())
-			writeResponse(w, upstreamBody)
-			return
-		}
+	// EmptyUpstream is set when the upstream handler returned no body at all
+	EmptyUpstream bool
+}
 
-		invocationCount2XXExpr := url.QueryEscape(`sum(gateway_function_invocation_total {function_name=~".*", code=~"2.*"}) by (function_name)`)
-		invocationCount2XXResults, fetchErr := prometheusQuery.Fetch(invocationCount2XXExpr)
+// metricsFetchError is a hard failure of the upstream handler itself (bad
+// status code or undecodable body), as opposed to a soft backend failure
+// which falls back to the unenriched function list instead.
+type metricsFetchError struct {
+	Status int
+	Body   string
+}
 
-		if fetchErr != nil {
-			log.Printf("Error querying Prometheus API: %s\n", fetchErr.Error())
-			writeResponse(w, upstreamBody)
-			return
-		}
+func (e *metricsFetchError) Error() string {
+	return e.Body
+}
 
-		invocationCountNon2XXExpr := url.QueryEscape(`sum(gateway_function_invocation_total {function_name=~".*", code!~"2.*"}) by (function_name)`)
-		invocationCountNon2XXResults, fetchErr := prometheusQuery.Fetch(invocationCountNon2XXExpr)
+// fetchMetrics calls the upstream handler for the function list and mixes in
+// totals and latencies from backend, so the result can be written either as a
+// single JSON response (AddMetricsHandler) or as one frame of many
+// (AddMetricsStreamHandler).
+func fetchMetrics(instrumented http.HandlerFunc, backend Backend, exporter *Exporter, r *http.Request) (*metricsFetchResult, *metricsFetchError) {
+	// log.Printf("Calling upstream for function info\n")
 
-		if fetchErr != nil {
-			log.Printf("Error querying Prometheus API: %s\n", fetchErr.Error())
-			writeResponse(w, upstreamBody)
-			return
-		}
+	recorder := httptest.NewRecorder()
+	instrumented.ServeHTTP(recorder, r)
+	upstreamCall := recorder.Result()
 
-		averageResponseTimeExpr := url.QueryEscape(`avg(gateway_functions_seconds_sum/gateway_functions_seconds_count {function_name=~".*"}) by (function_name)`)
-		averageResponseTimeResults, fetchErr := prometheusQuery.Fetch(averageResponseTimeExpr)
+	if upstreamCall.Body == nil {
+		log.Println("Upstream call had empty body.")
+		return &metricsFetchResult{EmptyUpstream: true}, nil
+	}
 
-		if fetchErr != nil {
-			log.Printf("Error querying Prometheus API: %s\n", fetchErr.Error())
-			writeResponse(w, upstreamBody)
-			return
+	defer upstreamCall.Body.Close()
+
+	if recorder.Code != http.StatusOK {
+		exporter.IncHandlerInternalError()
+		return nil, &metricsFetchError{
+			Status: http.StatusInternalServerError,
+			Body:   fmt.Sprintf("Error pulling metrics from provider/backend. Status code: %d", recorder.Code),
 		}
+	}
 
-		mixIn(&functions, results, invocationCount2XXResults, invocationCountNon2XXResults, averageResponseTimeResults)
+	upstreamBody, _ := ioutil.ReadAll(upstreamCall.Body)
+	var functions []requests.Function
 
-		bytesOut, marshalErr := json.Marshal(functions)
-		if marshalErr != nil {
-			log.Println(marshalErr)
-			return
+	if err := json.Unmarshal(upstreamBody, &functions); err != nil {
+		log.Printf("Metrics upstream error: %s", err)
+		exporter.IncHandlerInternalError()
+		return nil, &metricsFetchError{
+			Status: http.StatusInternalServerError,
+			Body:   "Error parsing metrics from upstream provider/backend.",
 		}
+	}
 
-		// log.Printf("Writing bytesOut: %s\n", bytesOut)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(bytesOut)
+	// log.Printf("Querying metrics backend\n")
+	totals, totalsWarnings, totalsErr := backend.InvocationTotals(r.Context())
+	if totalsErr != nil {
+		log.Printf("Error querying metrics backend: %s\n", totalsErr.Error())
+		return &metricsFetchResult{Fallback: upstreamBody}, nil
 	}
+
+	latencies, latencyWarnings, latenciesErr := backend.Latencies(r.Context())
+	if latenciesErr != nil {
+		log.Printf("Error querying metrics backend: %s\n", latenciesErr.Error())
+		return &metricsFetchResult{Fallback: upstreamBody}, nil
+	}
+
+	mixIn(&functions, totals, latencies)
+
+	warnings := append(totalsWarnings, latencyWarnings...)
+
+	return &metricsFetchResult{Functions: functions, Warnings: warnings}, nil
 }
 
-func mixIn(functions *[]requests.Function, invocationCountMetrics, invocationCount2XXMetrics, invocationCountNon2XXMetrics, averageResponseTimeMetrics *VectorQueryResponse) {
+// mixIn populates the invocation counts and latencies on functions from the
+// maps returned by a Backend, keyed by function name.
+func mixIn(functions *[]requests.Function, totals map[string]Counters, latencies map[string]Latency) {
 	if functions == nil {
 		return
 	}
@@ -119,55 +175,32 @@ func mixIn(functions *[]requests.Function, invocationCountMetrics, invocationCou
 		(*functions)[i].InvocationCount2XX = 0
 		(*functions)[i].InvocationCountNon2XX = 0
 		(*functions)[i].AverageResponseTime = 0
-
+		(*functions)[i].P95ResponseTime = 0
+		(*functions)[i].P99ResponseTime = 0
 	}
 
 	for i, function := range *functions {
-
-		for _, v := range invocationCountMetrics.Data.Result {
-
-			if v.Metric.FunctionName == function.Name {
-				parsedValue, err := parseMetricValue(v.Value[1])
-				if err == nil {
-					(*functions)[i].InvocationCount += parsedValue
-				}
-			}
+		if counters, ok := totals[function.Name]; ok {
+			(*functions)[i].InvocationCount = counters.Total
+			(*functions)[i].InvocationCount2XX = counters.Count2XX
+			(*functions)[i].InvocationCountNon2XX = counters.CountNon2XX
 		}
 
-		for _, v := range invocationCount2XXMetrics.Data.Result {
-
-			if v.Metric.FunctionName == function.Name {
-				parsedValue, err := parseMetricValue(v.Value[1])
-				if err == nil {
-					(*functions)[i].InvocationCount2XX += parsedValue
-				}
-			}
+		if latency, ok := latencies[function.Name]; ok {
+			(*functions)[i].AverageResponseTime = latency.Average
+			(*functions)[i].P95ResponseTime = latency.P95
+			(*functions)[i].P99ResponseTime = latency.P99
 		}
+	}
+}
 
-		for _, v := range invocationCountNon2XXMetrics.Data.Result {
-
-			if v.Metric.FunctionName == function.Name {
-				if v.Metric.FunctionName == function.Name {
-					parsedValue, err := parseMetricValue(v.Value[1])
-					if err == nil {
-						(*functions)[i].InvocationCountNon2XX += parsedValue
-					}
-				}
-			}
-		}
-
-		for _, v := range averageResponseTimeMetrics.Data.Result {
-
-			if v.Metric.FunctionName == function.Name {
-				if v.Metric.FunctionName == function.Name {
-					parsedValue, err := parseMetricValue(v.Value[1])
-					if err == nil {
-						(*functions)[i].AverageResponseTime += parsedValue
-					}
-				}
-			}
-		}
+// coerceNaN maps a NaN result (no traffic in the window) to 0, so that the
+// response stays valid JSON.
+func coerceNaN(value float64) float64 {
+	if math.IsNaN(value) {
+		return 0
 	}
+	return value
 }
 
 func writeResponse(w http.ResponseWriter, body []byte) {
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const envStreamInterval = "metrics_stream_interval"
+
+const defaultStreamInterval = 2 * time.Second
+
+// AddMetricsStreamHandler wraps handler with a Server-Sent Events endpoint
+// that pushes the enriched function list to the browser every interval,
+// reusing the same upstream call and mixIn as AddMetricsHandler. The
+// connection closes as soon as the client disconnects. interval defaults to
+// 2s and is overridable via the metrics_stream_interval environment variable
+// (whole seconds).
+func AddMetricsStreamHandler(handler http.HandlerFunc, backend Backend, exporter *Exporter) http.HandlerFunc {
+	instrumented := exporter.Instrument(handler)
+	interval := streamInterval()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported by the underlying ResponseWriter.", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				writeMetricsFrame(w, flusher, instrumented, backend, exporter, r)
+			}
+		}
+	}
+}
+
+// writeMetricsFrame fetches one round of metrics and writes it as a single SSE
+// frame. Fetch failures are logged and skipped, so a transient backend hiccup
+// doesn't tear down the stream.
+func writeMetricsFrame(w http.ResponseWriter, flusher http.Flusher, instrumented http.HandlerFunc, backend Backend, exporter *Exporter, r *http.Request) {
+	result, fetchErr := fetchMetrics(instrumented, backend, exporter, r)
+	if fetchErr != nil {
+		log.Println(fetchErr.Body)
+		return
+	}
+
+	if result.EmptyUpstream {
+		return
+	}
+
+	bytesOut := result.Fallback
+	if bytesOut == nil {
+		var marshalErr error
+		bytesOut, marshalErr = json.Marshal(result.Functions)
+		if marshalErr != nil {
+			log.Println(marshalErr)
+			exporter.IncHandlerInternalError()
+			return
+		}
+	}
+
+	fmt.Fprintf(w, "data: %s\n\n", bytesOut)
+	flusher.Flush()
+}
+
+// streamInterval reads the metrics_stream_interval environment variable
+// (whole seconds), falling back to defaultStreamInterval when unset or invalid.
+func streamInterval() time.Duration {
+	raw := os.Getenv(envStreamInterval)
+	if raw == "" {
+		return defaultStreamInterval
+	}
+
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 {
+		return defaultStreamInterval
+	}
+
+	return time.Duration(seconds) * time.Second
+}
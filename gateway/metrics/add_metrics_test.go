@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/openfaas/faas/gateway/requests"
+)
+
+func TestMixIn(t *testing.T) {
+	functions := []requests.Function{
+		{Name: "func1"},
+		{Name: "func2"},
+	}
+
+	totals := map[string]Counters{
+		"func1": {Total: 10, Count2XX: 8, CountNon2XX: 2},
+	}
+	latencies := map[string]Latency{
+		"func1": {Average: 0.5, P95: 0.9, P99: 1.2},
+	}
+
+	mixIn(&functions, totals, latencies)
+
+	got := functions[0]
+	if got.InvocationCount != 10 || got.InvocationCount2XX != 8 || got.InvocationCountNon2XX != 2 {
+		t.Fatalf("expected func1 counters to be mixed in, got %+v", got)
+	}
+	if got.AverageResponseTime != 0.5 || got.P95ResponseTime != 0.9 || got.P99ResponseTime != 1.2 {
+		t.Fatalf("expected func1 latencies to be mixed in, got %+v", got)
+	}
+
+	if untouched := functions[1]; untouched.InvocationCount != 0 || untouched.AverageResponseTime != 0 {
+		t.Fatalf("expected func2 with no metrics to stay zeroed, got %+v", untouched)
+	}
+}
+
+func TestMixInResetsStaleValues(t *testing.T) {
+	functions := []requests.Function{
+		{Name: "func1", InvocationCount: 99, P95ResponseTime: 42},
+	}
+
+	mixIn(&functions, map[string]Counters{}, map[string]Latency{})
+
+	if got := functions[0]; got.InvocationCount != 0 || got.P95ResponseTime != 0 {
+		t.Fatalf("expected stale values from a previous mixIn call to be reset to 0, got %+v", got)
+	}
+}
+
+func TestCoerceNaN(t *testing.T) {
+	if v := coerceNaN(math.NaN()); v != 0 {
+		t.Fatalf("expected NaN to be coerced to 0, got %v", v)
+	}
+	if v := coerceNaN(1.5); v != 1.5 {
+		t.Fatalf("expected a non-NaN value to pass through unchanged, got %v", v)
+	}
+}
+
+func TestCoerceNaNMarshalsCleanly(t *testing.T) {
+	function := requests.Function{Name: "func1", P95ResponseTime: coerceNaN(math.NaN())}
+
+	bytesOut, err := json.Marshal(function)
+	if err != nil {
+		t.Fatalf("expected a NaN-coerced function to marshal cleanly, got: %s", err)
+	}
+
+	if strings.Contains(string(bytesOut), "NaN") {
+		t.Fatalf("expected no raw NaN in the marshalled output, got: %s", bytesOut)
+	}
+}
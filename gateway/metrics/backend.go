@@ -0,0 +1,45 @@
+package metrics
+
+import "context"
+
+// Counters holds per-status invocation counts for a single function
+type Counters struct {
+	Total       float64
+	Count2XX    float64
+	CountNon2XX float64
+}
+
+// Latency holds the average and tail response times for a single function, in seconds
+type Latency struct {
+	Average float64
+	P95     float64
+	P99     float64
+}
+
+// Backend is a pluggable source of gateway metrics. AddMetricsHandler mixes the
+// result of a Backend into the upstream function list, so the gateway can run
+// against Prometheus, a Telegraf/InfluxDB stack, or nothing at all (Noop)
+// without changing the mix-in logic. Both methods return any non-fatal
+// storage warnings alongside their result, rather than a Backend stashing
+// them as shared state for a caller to read back out later.
+type Backend interface {
+	// InvocationTotals returns invocation counters for every function, keyed by function name
+	InvocationTotals(ctx context.Context) (map[string]Counters, []string, error)
+
+	// Latencies returns average and tail latency for every function, keyed by function name
+	Latencies(ctx context.Context) (map[string]Latency, []string, error)
+}
+
+// Noop is a Backend that returns no metrics, so the upstream function list is
+// passed through untouched. Useful for unit tests and dev setups without a TSDB.
+var Noop Backend = noopBackend{}
+
+type noopBackend struct{}
+
+func (noopBackend) InvocationTotals(ctx context.Context) (map[string]Counters, []string, error) {
+	return nil, nil, nil
+}
+
+func (noopBackend) Latencies(ctx context.Context) (map[string]Latency, []string, error) {
+	return nil, nil, nil
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"os"
+	"strconv"
+)
+
+const (
+	envWindow      = "metrics_window"
+	envP95Quantile = "metrics_p95_quantile"
+	envP99Quantile = "metrics_p99_quantile"
+
+	defaultWindow      = "5m"
+	defaultP95Quantile = 0.95
+	defaultP99Quantile = 0.99
+)
+
+// Config controls the range-vector window and quantiles used when querying
+// Prometheus for per-function tail latencies in AddMetricsHandler.
+type Config struct {
+	// Window is the range-vector duration passed to rate(), e.g. "5m"
+	Window string
+
+	// P95Quantile is the quantile used for Function.P95ResponseTime
+	P95Quantile float64
+
+	// P99Quantile is the quantile used for Function.P99ResponseTime
+	P99Quantile float64
+}
+
+// NewConfig returns the default Config, with the window and quantiles
+// overridable via the metrics_window, metrics_p95_quantile and
+// metrics_p99_quantile environment variables.
+func NewConfig() Config {
+	config := Config{
+		Window:      defaultWindow,
+		P95Quantile: defaultP95Quantile,
+		P99Quantile: defaultP99Quantile,
+	}
+
+	if window := os.Getenv(envWindow); window != "" {
+		config.Window = window
+	}
+
+	if quantile, err := strconv.ParseFloat(os.Getenv(envP95Quantile), 64); err == nil {
+		config.P95Quantile = quantile
+	}
+
+	if quantile, err := strconv.ParseFloat(os.Getenv(envP99Quantile), 64); err == nil {
+		config.P99Quantile = quantile
+	}
+
+	return config
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Exporter registers the gateway's own HTTP metrics and serves them over /metrics.
+// It is also used to instrument the metrics handler itself, so that scrape
+// failures and slow responses from the upstream provider are visible to operators.
+type Exporter struct {
+	Registry *prometheus.Registry
+
+	handlerRequestsTotal  *prometheus.CounterVec
+	handlerRequestsTiming *prometheus.HistogramVec
+	requestsInFlight      prometheus.Gauge
+	handlerInternalErrors prometheus.Counter
+}
+
+// NewExporter creates an Exporter with its own prometheus.Registry and registers
+// the collectors used to instrument AddMetricsHandler. The collectors are
+// namespaced under gateway_metrics_handler_* so they don't collide with the
+// gateway_function_invocation_total/gateway_functions_seconds series this
+// handler itself queries back from Prometheus.
+func NewExporter() *Exporter {
+	registry := prometheus.NewRegistry()
+
+	handlerRequestsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_metrics_handler_requests_total",
+		Help: "Total HTTP requests processed by the metrics handler, by code and method",
+	}, []string{"code", "method"})
+
+	handlerRequestsTiming := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "gateway_metrics_handler_request_duration_seconds",
+		Help: "Time taken to serve the metrics handler, in seconds",
+	}, []string{"code"})
+
+	requestsInFlight := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_metrics_handler_in_flight_requests",
+		Help: "Number of metrics handler requests currently being served",
+	})
+
+	handlerInternalErrors := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gateway_metrics_handler_internal_errors_total",
+		Help: "Number of times the metrics handler got a non-200 or undecodable upstream function list, or failed to encode its response",
+	})
+
+	registry.MustRegister(handlerRequestsTotal, handlerRequestsTiming, requestsInFlight, handlerInternalErrors)
+
+	return &Exporter{
+		Registry:              registry,
+		handlerRequestsTotal:  handlerRequestsTotal,
+		handlerRequestsTiming: handlerRequestsTiming,
+		requestsInFlight:      requestsInFlight,
+		handlerInternalErrors: handlerInternalErrors,
+	}
+}
+
+// Handler returns the promhttp handler for this Exporter's registry, to be mounted at /metrics
+func (e *Exporter) Handler() http.Handler {
+	return promhttp.HandlerFor(e.Registry, promhttp.HandlerOpts{})
+}
+
+// Instrument wraps handler so every call is counted, timed and tracked in-flight
+// using the standard promhttp middleware, which captures status code and byte
+// count via a promhttp.ResponseWriterDelegator internally.
+func (e *Exporter) Instrument(handler http.HandlerFunc) http.HandlerFunc {
+	instrumented := promhttp.InstrumentHandlerInFlight(e.requestsInFlight,
+		promhttp.InstrumentHandlerDuration(e.handlerRequestsTiming,
+			promhttp.InstrumentHandlerCounter(e.handlerRequestsTotal, handler)))
+
+	return instrumented.ServeHTTP
+}
+
+// IncHandlerInternalError records an internal failure of the metrics handler,
+// for paths (upstream 500s, JSON decode errors) that previously only logged.
+func (e *Exporter) IncHandlerInternalError() {
+	e.handlerInternalErrors.Inc()
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// InfluxDBBackend is a Backend for gateways fed by Telegraf into InfluxDB,
+// following the same remote-storage adapter pattern Prometheus itself uses for
+// third-party TSDBs: translate the gateway's own query vocabulary into the
+// backend's native query language, rather than teaching the mix-in logic about
+// it. Queries are InfluxQL sent to InfluxDB's /query endpoint; OpenTSDB's own
+// HTTP API is not spoken.
+type InfluxDBBackend struct {
+	Addr     string
+	Database string
+	Window   string
+	Client   *http.Client
+}
+
+// NewInfluxDBBackend creates an InfluxDBBackend querying database at addr
+// (e.g. "http://influxdb:8086") over window (e.g. "5m").
+func NewInfluxDBBackend(addr, database, window string, client *http.Client) *InfluxDBBackend {
+	if client == nil {
+		sharedClient := makeClient()
+		client = &sharedClient
+	}
+
+	return &InfluxDBBackend{
+		Addr:     addr,
+		Database: database,
+		Window:   window,
+		Client:   client,
+	}
+}
+
+// InvocationTotals queries InfluxDB for invocation counts grouped by function
+// name and status code.
+func (b *InfluxDBBackend) InvocationTotals(ctx context.Context) (map[string]Counters, []string, error) {
+	series, err := b.query(ctx, fmt.Sprintf(
+		`SELECT sum("value") FROM "gateway_function_invocation_total" WHERE time > now() - %s GROUP BY "function_name", "code"`, b.Window))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	counters := map[string]Counters{}
+	for _, s := range series {
+		total := s.lastValue()
+		entry := counters[s.Tags["function_name"]]
+		entry.Total += total
+		if len(s.Tags["code"]) > 0 && s.Tags["code"][0] == '2' {
+			entry.Count2XX += total
+		} else {
+			entry.CountNon2XX += total
+		}
+		counters[s.Tags["function_name"]] = entry
+	}
+
+	return counters, nil, nil
+}
+
+// Latencies queries InfluxDB for average and tail response times.
+func (b *InfluxDBBackend) Latencies(ctx context.Context) (map[string]Latency, []string, error) {
+	series, err := b.query(ctx, fmt.Sprintf(
+		`SELECT mean("value"), percentile("value", 95), percentile("value", 99) FROM "gateway_functions_seconds" WHERE time > now() - %s GROUP BY "function_name"`, b.Window))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	latencies := map[string]Latency{}
+	for _, s := range series {
+		if len(s.Values) == 0 || len(s.Values[len(s.Values)-1]) < 4 {
+			continue
+		}
+
+		row := s.Values[len(s.Values)-1]
+		latencies[s.Tags["function_name"]] = Latency{
+			Average: coerceNaN(toFloat(row[1])),
+			P95:     coerceNaN(toFloat(row[2])),
+			P99:     coerceNaN(toFloat(row[3])),
+		}
+	}
+
+	return latencies, nil, nil
+}
+
+type influxSeries struct {
+	Tags   map[string]string `json:"tags"`
+	Values [][]interface{}   `json:"values"`
+}
+
+func (s influxSeries) lastValue() float64 {
+	if len(s.Values) == 0 || len(s.Values[len(s.Values)-1]) < 2 {
+		return 0
+	}
+	return toFloat(s.Values[len(s.Values)-1][1])
+}
+
+type influxResponse struct {
+	Results []struct {
+		Series []influxSeries `json:"series"`
+	} `json:"results"`
+}
+
+func (b *InfluxDBBackend) query(ctx context.Context, query string) ([]influxSeries, error) {
+	endpoint := fmt.Sprintf("%s/query?db=%s&q=%s", b.Addr, url.QueryEscape(b.Database), url.QueryEscape(query))
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	res, err := b.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error querying InfluxDB, status: %d", res.StatusCode)
+	}
+
+	var decoded influxResponse
+	if err := json.NewDecoder(res.Body).Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	if len(decoded.Results) == 0 {
+		return nil, nil
+	}
+
+	return decoded.Results[0].Series, nil
+}
+
+func toFloat(value interface{}) float64 {
+	if v, ok := value.(float64); ok {
+		return v
+	}
+	return 0
+}
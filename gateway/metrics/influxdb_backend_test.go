@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc lets a plain function stand in for a http.RoundTripper, so
+// InfluxDBBackend can be tested against a canned response without a real
+// InfluxDB server.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func clientReturning(body string) *http.Client {
+	return &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+				Header:     make(http.Header),
+			}, nil
+		}),
+	}
+}
+
+func TestInfluxDBBackendInvocationTotals(t *testing.T) {
+	body := `{
+		"results": [
+			{
+				"series": [
+					{"tags": {"function_name": "func1", "code": "200"}, "values": [["2020-01-01T00:00:00Z", 8]]},
+					{"tags": {"function_name": "func1", "code": "500"}, "values": [["2020-01-01T00:00:00Z", 2]]}
+				]
+			}
+		]
+	}`
+
+	backend := NewInfluxDBBackend("http://influxdb:8086", "faas", "5m", clientReturning(body))
+
+	totals, warnings, err := backend.InvocationTotals(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if warnings != nil {
+		t.Fatalf("expected no warnings from the InfluxDB backend, got %v", warnings)
+	}
+
+	got := totals["func1"]
+	if got.Total != 10 || got.Count2XX != 8 || got.CountNon2XX != 2 {
+		t.Fatalf("unexpected counters: %+v", got)
+	}
+}
+
+func TestInfluxDBBackendLatenciesCoercesMissingValues(t *testing.T) {
+	body := `{
+		"results": [
+			{
+				"series": [
+					{"tags": {"function_name": "func1"}, "values": [["2020-01-01T00:00:00Z", 0.5, null, 1.2]]}
+				]
+			}
+		]
+	}`
+
+	backend := NewInfluxDBBackend("http://influxdb:8086", "faas", "5m", clientReturning(body))
+
+	latencies, _, err := backend.Latencies(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got := latencies["func1"]
+	if got.Average != 0.5 || got.P95 != 0 || got.P99 != 1.2 {
+		t.Fatalf("expected a missing percentile to be coerced to 0, got %+v", got)
+	}
+}
+
+func TestInfluxSeriesLastValue(t *testing.T) {
+	cases := []struct {
+		name   string
+		series influxSeries
+		want   float64
+	}{
+		{name: "empty series", series: influxSeries{}, want: 0},
+		{name: "single value row", series: influxSeries{Values: [][]interface{}{{"t", 4.0}}}, want: 4},
+		{name: "short row", series: influxSeries{Values: [][]interface{}{{"t"}}}, want: 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.series.lastValue(); got != c.want {
+				t.Fatalf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// PrometheusBackend is a Backend backed by a Prometheus server, via a
+// PrometheusQueryFetcher.
+type PrometheusBackend struct {
+	Fetcher PrometheusQueryFetcher
+	Config  Config
+}
+
+// NewPrometheusBackend wraps a PrometheusQueryFetcher as a Backend
+func NewPrometheusBackend(fetcher PrometheusQueryFetcher, config Config) *PrometheusBackend {
+	return &PrometheusBackend{Fetcher: fetcher, Config: config}
+}
+
+// InvocationTotals queries Prometheus for total, 2xx and non-2xx invocation
+// counts, batched into a single PrometheusQueryFetcher.FetchBatch call.
+func (b *PrometheusBackend) InvocationTotals(ctx context.Context) (map[string]Counters, []string, error) {
+	responses, warnings, err := b.Fetcher.FetchBatch(ctx, []Query{
+		{
+			Name: "invocation_total",
+			Expr: url.QueryEscape(`sum(gateway_function_invocation_total{function_name=~".*", code=~".*"}) by (function_name, code)`),
+		},
+		{
+			Name: "invocation_count_2xx",
+			Expr: url.QueryEscape(`sum(gateway_function_invocation_total {function_name=~".*", code=~"2.*"}) by (function_name)`),
+		},
+		{
+			Name: "invocation_count_non_2xx",
+			Expr: url.QueryEscape(`sum(gateway_function_invocation_total {function_name=~".*", code!~"2.*"}) by (function_name)`),
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	counters := map[string]Counters{}
+	mergeCounters(responses[0], counters, func(c *Counters, v float64) { c.Total += v })
+	mergeCounters(responses[1], counters, func(c *Counters, v float64) { c.Count2XX += v })
+	mergeCounters(responses[2], counters, func(c *Counters, v float64) { c.CountNon2XX += v })
+
+	return counters, warnings, nil
+}
+
+// Latencies queries Prometheus for average, P95 and P99 response times, using
+// the window and quantiles from Config.
+func (b *PrometheusBackend) Latencies(ctx context.Context) (map[string]Latency, []string, error) {
+	responses, warnings, err := b.Fetcher.FetchBatch(ctx, []Query{
+		{
+			Name: "average_response_time",
+			Expr: url.QueryEscape(`avg(gateway_functions_seconds_sum/gateway_functions_seconds_count {function_name=~".*"}) by (function_name)`),
+		},
+		{
+			Name: "p95_response_time",
+			Expr: url.QueryEscape(fmt.Sprintf(`histogram_quantile(%g, sum(rate(gateway_functions_seconds_bucket{function_name=~".*"}[%s])) by (le, function_name))`, b.Config.P95Quantile, b.Config.Window)),
+		},
+		{
+			Name: "p99_response_time",
+			Expr: url.QueryEscape(fmt.Sprintf(`histogram_quantile(%g, sum(rate(gateway_functions_seconds_bucket{function_name=~".*"}[%s])) by (le, function_name))`, b.Config.P99Quantile, b.Config.Window)),
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	latencies := map[string]Latency{}
+	mergeLatencies(responses[0], latencies, func(l *Latency, v float64) { l.Average += v })
+	mergeLatencies(responses[1], latencies, func(l *Latency, v float64) { l.P95 += coerceNaN(v) })
+	mergeLatencies(responses[2], latencies, func(l *Latency, v float64) { l.P99 += coerceNaN(v) })
+
+	return latencies, warnings, nil
+}
+
+// mergeCounters applies assign to the Counters entry for each result's
+// function_name label, creating it if necessary.
+func mergeCounters(response VectorQueryResponse, into map[string]Counters, assign func(*Counters, float64)) {
+	for _, v := range response.Data.Result {
+		parsedValue, err := parseMetricValue(v.Value[1])
+		if err != nil {
+			continue
+		}
+
+		entry := into[v.Metric.FunctionName]
+		assign(&entry, parsedValue)
+		into[v.Metric.FunctionName] = entry
+	}
+}
+
+// mergeLatencies applies assign to the Latency entry for each result's
+// function_name label, creating it if necessary.
+func mergeLatencies(response VectorQueryResponse, into map[string]Latency, assign func(*Latency, float64)) {
+	for _, v := range response.Data.Result {
+		parsedValue, err := parseMetricValue(v.Value[1])
+		if err != nil {
+			continue
+		}
+
+		entry := into[v.Metric.FunctionName]
+		assign(&entry, parsedValue)
+		into[v.Metric.FunctionName] = entry
+	}
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+var errFetchFailed = errors.New("fetch failed")
+
+// fakeFetcher is a canned PrometheusQueryFetcher, returning the same
+// responses, warnings and error from every call to FetchBatch regardless of
+// the queries passed in, so PrometheusBackend's merging logic can be tested
+// without a real Prometheus server.
+type fakeFetcher struct {
+	responses []VectorQueryResponse
+	warnings  []string
+	err       error
+}
+
+func (f *fakeFetcher) Fetch(query string) (*VectorQueryResponse, error) {
+	return nil, nil
+}
+
+func (f *fakeFetcher) FetchBatch(ctx context.Context, queries []Query) ([]VectorQueryResponse, []string, error) {
+	return f.responses, f.warnings, f.err
+}
+
+func vectorResult(functionName string, value string) VectorQueryResult {
+	return VectorQueryResult{
+		Metric: VectorQueryMetric{FunctionName: functionName},
+		Value:  []interface{}{0, value},
+	}
+}
+
+func TestPrometheusBackendInvocationTotals(t *testing.T) {
+	fetcher := &fakeFetcher{
+		responses: []VectorQueryResponse{
+			{Data: VectorQueryResponseData{Result: []VectorQueryResult{vectorResult("func1", "10")}}},
+			{Data: VectorQueryResponseData{Result: []VectorQueryResult{vectorResult("func1", "8")}}},
+			{Data: VectorQueryResponseData{Result: []VectorQueryResult{vectorResult("func1", "2")}}},
+		},
+		warnings: []string{"partial response"},
+	}
+
+	backend := NewPrometheusBackend(fetcher, NewConfig())
+
+	totals, warnings, err := backend.InvocationTotals(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got := totals["func1"]
+	if got.Total != 10 || got.Count2XX != 8 || got.CountNon2XX != 2 {
+		t.Fatalf("unexpected counters: %+v", got)
+	}
+
+	if len(warnings) != 1 || warnings[0] != "partial response" {
+		t.Fatalf("expected the batch's warnings to be returned, got %v", warnings)
+	}
+}
+
+func TestPrometheusBackendLatenciesCoercesNaN(t *testing.T) {
+	fetcher := &fakeFetcher{
+		responses: []VectorQueryResponse{
+			{Data: VectorQueryResponseData{Result: []VectorQueryResult{vectorResult("func1", "0.5")}}},
+			{Data: VectorQueryResponseData{Result: []VectorQueryResult{vectorResult("func1", "NaN")}}},
+			{Data: VectorQueryResponseData{Result: []VectorQueryResult{vectorResult("func1", "1.2")}}},
+		},
+	}
+
+	backend := NewPrometheusBackend(fetcher, NewConfig())
+
+	latencies, warnings, err := backend.Latencies(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if warnings != nil {
+		t.Fatalf("expected no warnings, got %v", warnings)
+	}
+
+	got := latencies["func1"]
+	if got.Average != 0.5 || got.P95 != 0 || got.P99 != 1.2 {
+		t.Fatalf("expected a NaN P95 to be coerced to 0, got %+v", got)
+	}
+}
+
+func TestPrometheusBackendInvocationTotalsPropagatesError(t *testing.T) {
+	wantErr := errFetchFailed
+	fetcher := &fakeFetcher{err: wantErr}
+	backend := NewPrometheusBackend(fetcher, NewConfig())
+
+	if _, _, err := backend.InvocationTotals(context.Background()); err != wantErr {
+		t.Fatalf("expected FetchBatch's error to propagate, got %v", err)
+	}
+}
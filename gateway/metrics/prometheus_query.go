@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// PrometheusQueryFetcher allows a client to fetch results from the Prometheus HTTP API
+type PrometheusQueryFetcher interface {
+	Fetch(query string) (*VectorQueryResponse, error)
+	FetchBatch(ctx context.Context, queries []Query) ([]VectorQueryResponse, []string, error)
+}
+
+// Query is a single named Prometheus expression, to be run as part of a FetchBatch call
+type Query struct {
+	Name string
+	Expr string
+}
+
+// PrometheusQuery is a concrete PrometheusQueryFetcher for a Prometheus server
+type PrometheusQuery struct {
+	Port   int
+	Host   string
+	Client *http.Client
+}
+
+// NewPrometheusQuery creates a PrometheusQueryFetcher for the given host/port. A
+// shared, keep-alive client is used when client is nil.
+func NewPrometheusQuery(host string, port int, client *http.Client) PrometheusQueryFetcher {
+	if client == nil {
+		sharedClient := makeClient()
+		client = &sharedClient
+	}
+
+	return &PrometheusQuery{
+		Client: client,
+		Host:   host,
+		Port:   port,
+	}
+}
+
+// Fetch queries Prometheus via /api/v1/query and returns the decoded vector response
+func (q *PrometheusQuery) Fetch(query string) (*VectorQueryResponse, error) {
+	return q.fetch(context.Background(), query)
+}
+
+// maxConcurrentQueries bounds the errgroup in FetchBatch, so a handler given a
+// large query list can't open unbounded concurrent connections to Prometheus.
+const maxConcurrentQueries = 4
+
+// FetchBatch fans out queries concurrently over the shared http.Client using a
+// bounded errgroup, and returns one VectorQueryResponse per input query, in the
+// same order, along with any Prometheus storage warnings collected across all
+// of them.
+func (q *PrometheusQuery) FetchBatch(ctx context.Context, queries []Query) ([]VectorQueryResponse, []string, error) {
+	responses := make([]VectorQueryResponse, len(queries))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(maxConcurrentQueries)
+	for i, query := range queries {
+		i, query := i, query
+
+		group.Go(func() error {
+			res, err := q.fetch(groupCtx, query.Expr)
+			if err != nil {
+				return fmt.Errorf("query %q: %s", query.Name, err)
+			}
+
+			responses[i] = *res
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	for _, res := range responses {
+		warnings = append(warnings, res.Warnings...)
+	}
+
+	return responses, warnings, nil
+}
+
+func (q *PrometheusQuery) fetch(ctx context.Context, query string) (*VectorQueryResponse, error) {
+	url := fmt.Sprintf("http://%s:%d/api/v1/query?query=%s", q.Host, q.Port, query)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	res, err := q.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	bytesOut, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error querying Prometheus, status: %d, body: %s", res.StatusCode, bytesOut)
+	}
+
+	var values VectorQueryResponse
+	if err := json.Unmarshal(bytesOut, &values); err != nil {
+		return nil, err
+	}
+
+	return &values, nil
+}
@@ -0,0 +1,30 @@
+package metrics
+
+// VectorQueryResponse is returned from a Prometheus range/instant vector query,
+// see: https://prometheus.io/docs/prometheus/latest/querying/api/
+type VectorQueryResponse struct {
+	Data VectorQueryResponseData `json:"data"`
+
+	// Warnings are non-fatal issues encountered while executing the query,
+	// for example a partial response from a store-gateway. Added upstream in
+	// client_golang 0.9.4 / Prometheus API v1.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// VectorQueryResponseData holds the result-set of a VectorQueryResponse
+type VectorQueryResponseData struct {
+	ResultType string              `json:"resultType"`
+	Result     []VectorQueryResult `json:"result"`
+}
+
+// VectorQueryResult is a single timeseries within a VectorQueryResponseData
+type VectorQueryResult struct {
+	Metric VectorQueryMetric `json:"metric"`
+	Value  []interface{}     `json:"value"`
+}
+
+// VectorQueryMetric holds the labels returned alongside a metric value
+type VectorQueryMetric struct {
+	FunctionName string `json:"function_name"`
+	Code         string `json:"code"`
+}
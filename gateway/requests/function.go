@@ -0,0 +1,46 @@
+// Package requests contains the types shared between the gateway's HTTP
+// handlers and the providers/backends they talk to.
+package requests
+
+// Function describes a deployed function, as returned by the
+// system/functions and system/function endpoints.
+type Function struct {
+	// Name of the function
+	Name string `json:"name"`
+
+	// Image is the fully qualified Docker image used by the function
+	Image string `json:"image"`
+
+	// InvocationCount is the total count of invocations, as reported by the metrics backend
+	InvocationCount float64 `json:"invocationCount"`
+
+	// InvocationCount2XX is the count of invocations returning a 2xx status code
+	InvocationCount2XX float64 `json:"invocationCount2XX,omitempty"`
+
+	// InvocationCountNon2XX is the count of invocations returning a non-2xx status code
+	InvocationCountNon2XX float64 `json:"invocationCountNon2XX,omitempty"`
+
+	// AverageResponseTime is the average time taken for invocations, in seconds
+	AverageResponseTime float64 `json:"averageResponseTime,omitempty"`
+
+	// P95ResponseTime is the 95th percentile response time for invocations, in seconds
+	P95ResponseTime float64 `json:"p95ResponseTime,omitempty"`
+
+	// P99ResponseTime is the 99th percentile response time for invocations, in seconds
+	P99ResponseTime float64 `json:"p99ResponseTime,omitempty"`
+
+	// Replicas is the current number of replicas for the function
+	Replicas uint64 `json:"replicas"`
+
+	// EnvProcess is the process to invoke for the function
+	EnvProcess string `json:"envProcess,omitempty"`
+
+	// EnvVars are environment variables to be given to the function
+	EnvVars map[string]string `json:"envVars,omitempty"`
+
+	// Labels are metadata for functions which may be used by the back-end for making scheduling or routing decisions
+	Labels *map[string]string `json:"labels,omitempty"`
+
+	// Annotations are metadata for functions which may be used by the back-end for management, orchestration, events and build tasks
+	Annotations *map[string]string `json:"annotations,omitempty"`
+}